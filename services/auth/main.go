@@ -1,22 +1,161 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
 	"net"
+	"os"
 
 	"server"
+	"server/auth/jwt"
+	"server/config"
+	"server/identity"
+	"server/oidc"
+	"server/policy"
+	"server/policy/cel"
+	"server/policy/rego"
 
-	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	httptransport "server/http"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
+	configPath := flag.String("config", "", "path to YAML config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	ctx := context.Background()
+	validator, err := jwt.NewValidator(ctx, cfg.JWT)
+	if err != nil {
+		log.Fatalf("init jwt validator: %v", err)
+	}
+	defer validator.Close()
+
+	policies, err := policy.NewEngine(cfg.PolicyDir, map[string]policy.Loader{
+		".cel":  cel.Load,
+		".rego": rego.Load,
+	})
+	if err != nil {
+		log.Fatalf("load policies: %v", err)
+	}
+	defer policies.Close()
+
+	identities, err := newIdentityStore(ctx, cfg.Identity)
+	if err != nil {
+		log.Fatalf("init identity store: %v", err)
+	}
+
+	var oidcMgr *oidc.Manager
+	if cfg.OIDC.ProviderURL != "" {
+		oidcMgr, err = oidc.NewManager(ctx, oidc.Config{
+			ProviderURL:       cfg.OIDC.ProviderURL,
+			ClientID:          cfg.OIDC.ClientID,
+			ClientSecret:      cfg.OIDC.ClientSecret,
+			RedirectURL:       cfg.OIDC.RedirectURL,
+			Scopes:            cfg.OIDC.Scopes,
+			CookieSecret:      cfg.OIDC.CookieSecret,
+			CallbackPath:      cfg.OIDC.CallbackPath,
+			ProtectedPrefixes: cfg.OIDC.ProtectedPrefixes,
+			DomainWhitelist:   cfg.OIDC.DomainWhitelist,
+			RefreshSkew:       cfg.OIDC.RefreshSkew,
+		})
+		if err != nil {
+			log.Fatalf("init oidc manager: %v", err)
+		}
+	}
+
+	core := server.NewServer(validator, policies, identities, oidcMgr)
+
+	errCh := make(chan error, 2)
+	go func() { errCh <- serveGRPC(cfg.GRPCAddr, core) }()
+	go func() { errCh <- serveHTTP(cfg.HTTPAddr, core) }()
+
+	log.Fatal(<-errCh)
+}
+
+func newIdentityStore(ctx context.Context, cfg config.Identity) (identity.Store, error) {
+	var store identity.Store
+
+	switch cfg.Kind {
+	case "file":
+		s, err := identity.LoadFileStore(cfg.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	case "redis":
+		store = identity.NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}), cfg.RedisKeyPrefix)
+	case "grpc":
+		creds, err := grpcIdentityCredentials(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s, err := identity.DialGRPCStore(ctx, cfg.GRPCTarget, cfg.GRPCToken, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	default:
+		return nil, fmt.Errorf("unknown identity store kind %q", cfg.Kind)
+	}
+
+	return identity.NewCachingStore(store, cfg.CacheTTL), nil
+}
+
+// grpcIdentityCredentials builds the transport credentials for dialing the
+// gRPC identity backend. GRPCInsecure is only appropriate for local
+// development; otherwise the connection is verified via TLS, optionally
+// against a CA bundle and server name supplied in cfg.
+func grpcIdentityCredentials(cfg config.Identity) (credentials.TransportCredentials, error) {
+	if cfg.GRPCInsecure {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsCfg := &tls.Config{ServerName: cfg.GRPCServerName}
+	if cfg.GRPCCACertFile != "" {
+		pem, err := os.ReadFile(cfg.GRPCCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("read grpc ca cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("parse grpc ca cert %s", cfg.GRPCCACertFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+func serveGRPC(addr string, core *server.Server) error {
 	s := grpc.NewServer()
-	auth.RegisterAuthorizationServer(s, &server.Server{})
+	authv3.RegisterAuthorizationServer(s, core)
 
-	lis, err := net.Listen("tcp", ":5005")
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
-		return
+		return err
 	}
 
-	s.Serve(lis)
+	return s.Serve(lis)
+}
+
+func serveHTTP(addr string, core *server.Server) error {
+	return httptransport.NewServer(addr, core).ListenAndServe()
 }
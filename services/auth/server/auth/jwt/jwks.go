@@ -0,0 +1,253 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+// jwksSource fetches a JSON Web Key Set over HTTP and keeps it refreshed in
+// the background, serving parsed public keys to jwt.Keyfunc lookups by kid.
+type jwksSource struct {
+	url    string
+	client *http.Client
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu   sync.RWMutex
+	keys map[string]interface{} // kid -> *rsa.PublicKey | *ecdsa.PublicKey
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// X.509 certificate chain, used as a fallback when n/e or x/y are absent.
+	X5c []string `json:"x5c"`
+}
+
+func newJWKSSource(ctx context.Context, url string, refresh time.Duration) (*jwksSource, error) {
+	if refresh <= 0 {
+		refresh = 5 * time.Minute
+	}
+
+	s := &jwksSource{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		done:   make(chan struct{}),
+	}
+
+	if err := s.fetch(ctx); err != nil {
+		return nil, err
+	}
+
+	refreshCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	go s.refreshLoop(refreshCtx, refresh)
+
+	return s, nil
+}
+
+func (s *jwksSource) refreshLoop(ctx context.Context, interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Best effort: keep serving the last good key set on error.
+			_ = s.fetch(ctx)
+		}
+	}
+}
+
+func (s *jwksSource) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks: unexpected status %d from %s", resp.StatusCode, s.url)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("jwks: decode: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		return k.rsaPublicKey()
+	case "EC":
+		return k.ecPublicKey()
+	default:
+		return nil, fmt.Errorf("jwks: unsupported kty %q", k.Kty)
+	}
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	if k.N != "" && k.E != "" {
+		nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decode n: %w", err)
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decode e: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(new(big.Int).SetBytes(eBytes).Int64()),
+		}, nil
+	}
+	return k.certPublicKey()
+}
+
+func (k jwk) ecPublicKey() (*ecdsa.PublicKey, error) {
+	if k.X != "" && k.Y != "" {
+		xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decode x: %w", err)
+		}
+		yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decode y: %w", err)
+		}
+
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwks: unsupported curve %q", k.Crv)
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(xBytes),
+			Y:     new(big.Int).SetBytes(yBytes),
+		}, nil
+	}
+
+	pk, err := k.certAnyPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := pk.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("jwks: x5c certificate is not an EC key")
+	}
+	return ecKey, nil
+}
+
+func (k jwk) certPublicKey() (*rsa.PublicKey, error) {
+	pk, err := k.certAnyPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := pk.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("jwks: x5c certificate is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func (k jwk) certAnyPublicKey() (interface{}, error) {
+	if len(k.X5c) == 0 {
+		return nil, fmt.Errorf("jwks: key %q has no usable key material", k.Kid)
+	}
+	der, err := base64.StdEncoding.DecodeString(k.X5c[0])
+	if err != nil {
+		return nil, fmt.Errorf("jwks: decode x5c: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: parse x5c: %w", err)
+	}
+	return cert.PublicKey, nil
+}
+
+func (s *jwksSource) keyFunc(t *jwtlib.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid != "" {
+		if key, ok := s.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("jwks: unknown kid %q", kid)
+	}
+
+	if len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("jwks: token has no kid and key set is ambiguous")
+}
+
+// Close stops the background refresh loop and waits for it to exit.
+func (s *jwksSource) Close() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
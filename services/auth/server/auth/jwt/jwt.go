@@ -0,0 +1,153 @@
+// Package jwt validates bearer tokens presented to the ext_authz server,
+// either via a shared HS256 secret or against an RS256/ES256 JSON Web Key
+// Set fetched from a configured URL and refreshed periodically.
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+
+	"server/config"
+)
+
+// Reason classifies why a token failed validation, so callers can surface a
+// specific message to the caller instead of a generic "unauthenticated".
+type Reason string
+
+const (
+	ReasonNone          Reason = ""
+	ReasonMissingToken  Reason = "missing token"
+	ReasonBadSignature  Reason = "bad signature"
+	ReasonExpired       Reason = "token expired"
+	ReasonNotYetValid   Reason = "token not yet valid"
+	ReasonWrongIssuer   Reason = "wrong issuer"
+	ReasonWrongAudience Reason = "wrong audience"
+	ReasonMalformed     Reason = "malformed token"
+)
+
+// ValidationError wraps a validation failure together with its Reason so
+// callers can build an appropriate denied response.
+type ValidationError struct {
+	Reason Reason
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("jwt: %s: %v", e.Reason, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Claims are the JWT claims the ext_authz server understands. Role may be
+// empty if the issuing party did not set it.
+type Claims struct {
+	jwtlib.RegisteredClaims
+	Role string `json:"role,omitempty"`
+}
+
+// Validator parses and verifies bearer tokens according to a config.JWT.
+type Validator struct {
+	cfg     config.JWT
+	keyFunc jwtlib.Keyfunc
+	jwks    *jwksSource
+}
+
+// NewValidator builds a Validator from cfg. Exactly one of HS256Secret or
+// JWKSURL is expected to be set; if JWKSURL is set, its keys are fetched
+// immediately and then refreshed every cfg.JWKSRefreshInterval until ctx is
+// done.
+func NewValidator(ctx context.Context, cfg config.JWT) (*Validator, error) {
+	v := &Validator{cfg: cfg}
+
+	switch {
+	case cfg.HS256Secret != "":
+		secret := []byte(cfg.HS256Secret)
+		v.keyFunc = func(t *jwtlib.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwtlib.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+			return secret, nil
+		}
+	case cfg.JWKSURL != "":
+		jwks, err := newJWKSSource(ctx, cfg.JWKSURL, cfg.JWKSRefreshInterval)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: fetch jwks: %w", err)
+		}
+		v.jwks = jwks
+		v.keyFunc = jwks.keyFunc
+	default:
+		return nil, errors.New("jwt: either HS256Secret or JWKSURL must be configured")
+	}
+
+	return v, nil
+}
+
+// Close stops the background JWKS refresh goroutine, if any.
+func (v *Validator) Close() {
+	if v.jwks != nil {
+		v.jwks.Close()
+	}
+}
+
+// HeaderName returns the HTTP header the token is expected in, e.g.
+// "authorization".
+func (v *Validator) HeaderName() string {
+	return v.cfg.HeaderName
+}
+
+// ExtractToken pulls the bearer token out of a raw header value such as
+// "Bearer eyJ...". It returns false if no token is present.
+func ExtractToken(headerValue string) (string, bool) {
+	const prefix = "Bearer "
+	if strings.HasPrefix(headerValue, prefix) {
+		headerValue = headerValue[len(prefix):]
+	}
+	headerValue = strings.TrimSpace(headerValue)
+	if headerValue == "" {
+		return "", false
+	}
+	return headerValue, true
+}
+
+// Validate parses and verifies tokenString, checking signature, iss, aud,
+// exp and nbf. On failure it returns a *ValidationError describing the
+// specific reason.
+func (v *Validator) Validate(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	parser := jwtlib.NewParser(
+		jwtlib.WithIssuer(v.cfg.Issuer),
+		jwtlib.WithAudience(v.cfg.Audience),
+		jwtlib.WithExpirationRequired(),
+	)
+
+	_, err := parser.ParseWithClaims(tokenString, claims, v.keyFunc)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	return claims, nil
+}
+
+func classify(err error) *ValidationError {
+	switch {
+	case errors.Is(err, jwtlib.ErrTokenExpired):
+		return &ValidationError{Reason: ReasonExpired, Err: err}
+	case errors.Is(err, jwtlib.ErrTokenNotValidYet):
+		return &ValidationError{Reason: ReasonNotYetValid, Err: err}
+	case errors.Is(err, jwtlib.ErrTokenSignatureInvalid):
+		return &ValidationError{Reason: ReasonBadSignature, Err: err}
+	case errors.Is(err, jwtlib.ErrTokenInvalidIssuer):
+		return &ValidationError{Reason: ReasonWrongIssuer, Err: err}
+	case errors.Is(err, jwtlib.ErrTokenInvalidAudience):
+		return &ValidationError{Reason: ReasonWrongAudience, Err: err}
+	case errors.Is(err, jwtlib.ErrTokenMalformed):
+		return &ValidationError{Reason: ReasonMalformed, Err: err}
+	default:
+		return &ValidationError{Reason: ReasonMalformed, Err: err}
+	}
+}
@@ -0,0 +1,231 @@
+// Package config loads the ext_authz server configuration from a YAML file,
+// with individual fields overridable by environment variables so the same
+// binary can be configured either way in tests or in production.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JWT holds the settings needed to validate inbound bearer tokens.
+type JWT struct {
+	// HeaderName is the HTTP header carrying the token, e.g. "authorization".
+	HeaderName string `yaml:"header_name"`
+	// Issuer and Audience are required claims checked on every token.
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// HS256Secret, when set, enables HMAC verification.
+	HS256Secret string `yaml:"hs256_secret"`
+	// JWKSURL, when set, enables RS256/ES256 verification against a remote
+	// JSON Web Key Set, refreshed every JWKSRefreshInterval.
+	JWKSURL             string        `yaml:"jwks_url"`
+	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval"`
+}
+
+// Identity configures how contract IDs are resolved for a token subject.
+// Kind selects which backend is used: "file", "redis" or "grpc".
+type Identity struct {
+	Kind string `yaml:"kind"`
+
+	// File backend.
+	FilePath string `yaml:"file_path"`
+
+	// Redis backend.
+	RedisAddr      string `yaml:"redis_addr"`
+	RedisPassword  string `yaml:"redis_password"`
+	RedisDB        int    `yaml:"redis_db"`
+	RedisKeyPrefix string `yaml:"redis_key_prefix"`
+
+	// gRPC backend.
+	GRPCTarget string `yaml:"grpc_target"`
+	GRPCToken  string `yaml:"grpc_token"`
+
+	// GRPCInsecure disables transport security for the gRPC backend. Only
+	// appropriate for local development; production targets should leave
+	// this false and rely on GRPCCACertFile (or the system cert pool).
+	GRPCInsecure bool `yaml:"grpc_insecure"`
+	// GRPCCACertFile, if set, is a PEM-encoded CA bundle used to verify the
+	// gRPC backend's certificate instead of the system cert pool.
+	GRPCCACertFile string `yaml:"grpc_ca_cert_file"`
+	// GRPCServerName overrides the server name used for TLS verification,
+	// e.g. when GRPCTarget is an IP address.
+	GRPCServerName string `yaml:"grpc_server_name"`
+
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// OIDC configures the ext_authz server as an OIDC relying party for browser
+// traffic, letting Check redirect unauthenticated requests to protected
+// paths into a login flow instead of denying them outright. It is left
+// unconfigured (ProviderURL == "") unless OIDC login is in use.
+type OIDC struct {
+	ProviderURL  string   `yaml:"provider_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+
+	// CookieSecret encrypts and authenticates the state and session
+	// cookies; it is stretched to an AES-256 key via SHA-256.
+	CookieSecret string `yaml:"cookie_secret"`
+
+	// CallbackPath is where the HTTP transport mounts the code-exchange
+	// callback, e.g. "/oidc/callback".
+	CallbackPath string `yaml:"callback_path"`
+
+	// ProtectedPrefixes lists the request path prefixes that require an
+	// OIDC session when no bearer token is present.
+	ProtectedPrefixes []string `yaml:"protected_prefixes"`
+
+	// DomainWhitelist restricts which hosts a post-login redirect may
+	// point at when the original request target is an absolute URL.
+	DomainWhitelist []string `yaml:"domain_whitelist"`
+
+	// RefreshSkew is how far ahead of expiry an access token is
+	// transparently refreshed using its refresh token.
+	RefreshSkew time.Duration `yaml:"refresh_skew"`
+}
+
+// Config is the root configuration object for the ext_authz server.
+type Config struct {
+	JWT       JWT      `yaml:"jwt"`
+	PolicyDir string   `yaml:"policy_dir"`
+	Identity  Identity `yaml:"identity"`
+	OIDC      OIDC     `yaml:"oidc"`
+	// GRPCAddr and HTTPAddr are the listen addresses for the gRPC and HTTP
+	// ext_authz transports, respectively. Both are started concurrently.
+	GRPCAddr string `yaml:"grpc_addr"`
+	HTTPAddr string `yaml:"http_addr"`
+}
+
+func defaults() *Config {
+	return &Config{
+		JWT: JWT{
+			HeaderName:          "authorization",
+			JWKSRefreshInterval: 5 * time.Minute,
+		},
+		PolicyDir: "policies",
+		Identity: Identity{
+			Kind:           "file",
+			FilePath:       "identities.yaml",
+			RedisKeyPrefix: "contract:",
+			CacheTTL:       time.Minute,
+		},
+		OIDC: OIDC{
+			CallbackPath: "/oidc/callback",
+			RefreshSkew:  time.Minute,
+		},
+		GRPCAddr: ":5005",
+		HTTPAddr: ":5006",
+	}
+}
+
+// Load reads the YAML configuration at path, if non-empty, and then applies
+// environment variable overrides on top of it. It always returns a usable
+// Config, falling back to defaults when path is empty.
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: read %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: parse %s: %w", path, err)
+		}
+	}
+
+	applyEnvOverrides(cfg)
+
+	return cfg, nil
+}
+
+func applyEnvOverrides(cfg *Config) {
+	if v, ok := os.LookupEnv("AUTH_JWT_HEADER_NAME"); ok {
+		cfg.JWT.HeaderName = v
+	}
+	if v, ok := os.LookupEnv("AUTH_JWT_ISSUER"); ok {
+		cfg.JWT.Issuer = v
+	}
+	if v, ok := os.LookupEnv("AUTH_JWT_AUDIENCE"); ok {
+		cfg.JWT.Audience = v
+	}
+	if v, ok := os.LookupEnv("AUTH_JWT_HS256_SECRET"); ok {
+		cfg.JWT.HS256Secret = v
+	}
+	if v, ok := os.LookupEnv("AUTH_JWT_JWKS_URL"); ok {
+		cfg.JWT.JWKSURL = v
+	}
+	if v, ok := os.LookupEnv("AUTH_JWT_JWKS_REFRESH_INTERVAL"); ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			cfg.JWT.JWKSRefreshInterval = time.Duration(secs) * time.Second
+		}
+	}
+	if v, ok := os.LookupEnv("AUTH_POLICY_DIR"); ok {
+		cfg.PolicyDir = v
+	}
+	if v, ok := os.LookupEnv("AUTH_GRPC_ADDR"); ok {
+		cfg.GRPCAddr = v
+	}
+	if v, ok := os.LookupEnv("AUTH_HTTP_ADDR"); ok {
+		cfg.HTTPAddr = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_KIND"); ok {
+		cfg.Identity.Kind = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_FILE_PATH"); ok {
+		cfg.Identity.FilePath = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_REDIS_ADDR"); ok {
+		cfg.Identity.RedisAddr = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_REDIS_PASSWORD"); ok {
+		cfg.Identity.RedisPassword = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_GRPC_TARGET"); ok {
+		cfg.Identity.GRPCTarget = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_GRPC_TOKEN"); ok {
+		cfg.Identity.GRPCToken = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_GRPC_INSECURE"); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.Identity.GRPCInsecure = b
+		}
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_GRPC_CA_CERT_FILE"); ok {
+		cfg.Identity.GRPCCACertFile = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_GRPC_SERVER_NAME"); ok {
+		cfg.Identity.GRPCServerName = v
+	}
+	if v, ok := os.LookupEnv("AUTH_IDENTITY_CACHE_TTL"); ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			cfg.Identity.CacheTTL = time.Duration(secs) * time.Second
+		}
+	}
+	if v, ok := os.LookupEnv("AUTH_OIDC_PROVIDER_URL"); ok {
+		cfg.OIDC.ProviderURL = v
+	}
+	if v, ok := os.LookupEnv("AUTH_OIDC_CLIENT_ID"); ok {
+		cfg.OIDC.ClientID = v
+	}
+	if v, ok := os.LookupEnv("AUTH_OIDC_CLIENT_SECRET"); ok {
+		cfg.OIDC.ClientSecret = v
+	}
+	if v, ok := os.LookupEnv("AUTH_OIDC_REDIRECT_URL"); ok {
+		cfg.OIDC.RedirectURL = v
+	}
+	if v, ok := os.LookupEnv("AUTH_OIDC_COOKIE_SECRET"); ok {
+		cfg.OIDC.CookieSecret = v
+	}
+	if v, ok := os.LookupEnv("AUTH_OIDC_CALLBACK_PATH"); ok {
+		cfg.OIDC.CallbackPath = v
+	}
+}
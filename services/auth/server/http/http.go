@@ -0,0 +1,143 @@
+// Package http mounts Envoy's HTTP ext_authz callout alongside the existing
+// gRPC transport, backed by the same server.Server decision core so that
+// policy, JWT and config changes apply to both listeners.
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"server"
+)
+
+// Handler implements Envoy's HTTP ext_authz contract: incoming request
+// headers are mapped to a server.DecisionInput, the result is applied as a
+// plain HTTP status plus x-auth-* response headers on allow, or the deny
+// body on a non-2xx status.
+type Handler struct {
+	core *server.Server
+}
+
+// NewHandler builds a Handler backed by core.
+func NewHandler(core *server.Server) *Handler {
+	return &Handler{core: core}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	headers := make(map[string]string, len(r.Header))
+	for k := range r.Header {
+		headers[strings.ToLower(k)] = r.Header.Get(k)
+	}
+
+	result, err := h.core.Decide(r.Context(), server.DecisionInput{
+		Method:             r.Method,
+		Path:               r.URL.Path,
+		Headers:            headers,
+		SourceAddress:      sourceAddress(r),
+		DestinationAddress: destinationAddress(r),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !result.Allow {
+		for k, v := range result.HeadersToAdd {
+			w.Header().Set(k, v)
+		}
+		setCookies(w, result.SetCookies)
+
+		status := int(result.Status)
+		if status == 0 {
+			status = http.StatusForbidden
+		}
+		http.Error(w, result.Body, status)
+		return
+	}
+
+	for _, name := range result.HeadersToRemove {
+		r.Header.Del(name)
+	}
+	for k, v := range result.HeadersToAdd {
+		w.Header().Set(k, v)
+	}
+	setCookies(w, result.SetCookies)
+	w.WriteHeader(http.StatusOK)
+}
+
+// sourceAddress recovers the true downstream client address for parity with
+// the gRPC transport's peerAddress(Attributes.Source). Envoy's HTTP
+// ext_authz callout is itself the client as far as net/http is concerned, so
+// r.RemoteAddr is Envoy's own address, not the original caller's.
+//
+// X-Envoy-External-Address is set by Envoy's HTTP connection manager to the
+// address it observed for the immediate downstream connection and cannot be
+// set by the original caller, so it is preferred when present. Falling back
+// to X-Forwarded-For requires taking the *last* entry: Envoy appends its
+// observed downstream address to the end of any existing list rather than
+// prepending it (the same convention nginx and most proxies follow), so
+// earlier entries are whatever the untrusted client put there.
+func sourceAddress(r *http.Request) string {
+	if ext := strings.TrimSpace(r.Header.Get("X-Envoy-External-Address")); ext != "" {
+		return ext
+	}
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		if i := strings.LastIndexByte(fwd, ','); i >= 0 {
+			fwd = fwd[i+1:]
+		}
+		return strings.TrimSpace(fwd)
+	}
+	return r.RemoteAddr
+}
+
+// destinationAddress recovers the original request's destination for parity
+// with the gRPC transport's peerAddress(Attributes.Destination). r.Host is
+// the client-supplied Host header on the authz callout itself, not a socket
+// address; X-Forwarded-Host carries the authority of the request Envoy is
+// actually authorizing and must be forwarded for policies that key off it to
+// behave the same on both transports.
+func destinationAddress(r *http.Request) string {
+	if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+		return host
+	}
+	return r.Host
+}
+
+func setCookies(w http.ResponseWriter, cookies []*http.Cookie) {
+	for _, c := range cookies {
+		http.SetCookie(w, c)
+	}
+}
+
+// NewServer builds an *http.Server serving Handler on addr, plus the OIDC
+// code-exchange callback on core.OIDCCallbackPath() when OIDC is configured.
+func NewServer(addr string, core *server.Server) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/", NewHandler(core))
+	if path := core.OIDCCallbackPath(); path != "" {
+		mux.HandleFunc(path, oidcCallbackHandler(core))
+	}
+
+	return &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+}
+
+// oidcCallbackHandler completes the OIDC authorization code exchange for an
+// incoming callback request, sets the resulting session cookie(s), and
+// redirects the browser back to where it originally tried to go.
+func oidcCallbackHandler(core *server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		result, err := core.FinishOIDCLogin(r.Context(), r.Header.Get("Cookie"), q.Get("code"), q.Get("state"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		setCookies(w, result.Cookies)
+		http.Redirect(w, r, result.RedirectTo, http.StatusFound)
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSourceAddress(t *testing.T) {
+	cases := []struct {
+		name         string
+		externalAddr string
+		forwardedFor string
+		remoteAddr   string
+		want         string
+	}{
+		{
+			name:         "prefers x-envoy-external-address",
+			externalAddr: "203.0.113.7",
+			forwardedFor: "10.0.0.1, 203.0.113.7",
+			remoteAddr:   "127.0.0.1:9901",
+			want:         "203.0.113.7",
+		},
+		{
+			name:         "trusts the last X-Forwarded-For hop, not the client-spoofed first",
+			forwardedFor: "10.0.0.1, 203.0.113.7",
+			remoteAddr:   "127.0.0.1:9901",
+			want:         "203.0.113.7",
+		},
+		{
+			name:         "single-hop X-Forwarded-For",
+			forwardedFor: "203.0.113.7",
+			remoteAddr:   "127.0.0.1:9901",
+			want:         "203.0.113.7",
+		},
+		{
+			name:       "falls back to RemoteAddr absent any forwarded header",
+			remoteAddr: "203.0.113.7:54321",
+			want:       "203.0.113.7:54321",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/widgets", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			if tc.externalAddr != "" {
+				r.Header.Set("X-Envoy-External-Address", tc.externalAddr)
+			}
+
+			if got := sourceAddress(r); got != tc.want {
+				t.Errorf("sourceAddress() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
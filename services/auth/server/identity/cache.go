@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type cacheEntry struct {
+	contractID string
+	expiresAt  time.Time
+}
+
+// CachingStore wraps another Store, remembering resolved mappings for ttl
+// and collapsing concurrent lookups of the same subject into one call to
+// next.
+type CachingStore struct {
+	next Store
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+
+	group singleflight.Group
+}
+
+// NewCachingStore wraps next with an in-memory cache of resolved contract
+// IDs, each valid for ttl.
+func NewCachingStore(next Store, ttl time.Duration) *CachingStore {
+	return &CachingStore{
+		next:    next,
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *CachingStore) LookupContract(ctx context.Context, subject string) (string, error) {
+	if id, ok := c.cached(subject); ok {
+		return id, nil
+	}
+
+	v, err, _ := c.group.Do(subject, func() (interface{}, error) {
+		if id, ok := c.cached(subject); ok {
+			return id, nil
+		}
+
+		id, err := c.next.LookupContract(ctx, subject)
+		if err != nil {
+			return "", err
+		}
+
+		c.mu.Lock()
+		c.entries[subject] = cacheEntry{contractID: id, expiresAt: time.Now().Add(c.ttl)}
+		c.mu.Unlock()
+
+		return id, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+func (c *CachingStore) cached(subject string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[subject]
+	if !ok || time.Now().After(e.expiresAt) {
+		return "", false
+	}
+	return e.contractID, true
+}
@@ -0,0 +1,115 @@
+package identity_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"server/identity"
+)
+
+// countingStore wraps a fixed mapping and counts how many times
+// LookupContract actually runs, so tests can assert on cache hits/misses.
+type countingStore struct {
+	mapping map[string]string
+	calls   int32
+}
+
+func (s *countingStore) LookupContract(ctx context.Context, subject string) (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	id, ok := s.mapping[subject]
+	if !ok {
+		return "", identity.ErrNotFound
+	}
+	return id, nil
+}
+
+func TestCachingStore_CachesWithinTTL(t *testing.T) {
+	next := &countingStore{mapping: map[string]string{"alice": "contract-alice"}}
+	s := identity.NewCachingStore(next, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		id, err := s.LookupContract(context.Background(), "alice")
+		if err != nil {
+			t.Fatalf("LookupContract: %v", err)
+		}
+		if id != "contract-alice" {
+			t.Fatalf("LookupContract = %q, want contract-alice", id)
+		}
+	}
+
+	if got := atomic.LoadInt32(&next.calls); got != 1 {
+		t.Fatalf("next.calls = %d, want 1 (subsequent lookups served from cache)", got)
+	}
+}
+
+func TestCachingStore_EntryExpiresAfterTTL(t *testing.T) {
+	next := &countingStore{mapping: map[string]string{"alice": "contract-alice"}}
+	s := identity.NewCachingStore(next, 10*time.Millisecond)
+
+	if _, err := s.LookupContract(context.Background(), "alice"); err != nil {
+		t.Fatalf("LookupContract: %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := s.LookupContract(context.Background(), "alice"); err != nil {
+		t.Fatalf("LookupContract: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&next.calls); got != 2 {
+		t.Fatalf("next.calls = %d, want 2 (cache entry should have expired)", got)
+	}
+}
+
+func TestCachingStore_CollapsesConcurrentLookups(t *testing.T) {
+	release := make(chan struct{})
+	next := &blockingStore{mapping: map[string]string{"alice": "contract-alice"}, release: release}
+	s := identity.NewCachingStore(next, time.Hour)
+
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			id, err := s.LookupContract(context.Background(), "alice")
+			if err != nil {
+				t.Errorf("LookupContract: %v", err)
+			}
+			if id != "contract-alice" {
+				t.Errorf("LookupContract = %q, want contract-alice", id)
+			}
+		}()
+	}
+
+	// Give every goroutine a chance to reach the store before releasing the
+	// single in-flight call they should all be collapsed into.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&next.calls); got != 1 {
+		t.Fatalf("next.calls = %d, want 1 (concurrent lookups should collapse into one call)", got)
+	}
+}
+
+// blockingStore blocks every LookupContract call until release is closed, so
+// a test can hold several concurrent callers in flight at once.
+type blockingStore struct {
+	mapping map[string]string
+	release <-chan struct{}
+	calls   int32
+}
+
+func (s *blockingStore) LookupContract(ctx context.Context, subject string) (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.release
+	id, ok := s.mapping[subject]
+	if !ok {
+		return "", identity.ErrNotFound
+	}
+	return id, nil
+}
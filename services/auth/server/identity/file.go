@@ -0,0 +1,65 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileStore resolves contracts from a static subject -> contract ID mapping
+// loaded from a YAML or JSON file, chosen by its extension.
+type FileStore struct {
+	mu      sync.RWMutex
+	mapping map[string]string
+}
+
+// LoadFileStore reads the subject -> contract ID mapping at path.
+func LoadFileStore(path string) (*FileStore, error) {
+	s := &FileStore{}
+	if err := s.reload(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("identity: read %s: %w", path, err)
+	}
+
+	mapping := make(map[string]string)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &mapping); err != nil {
+			return fmt.Errorf("identity: parse %s: %w", path, err)
+		}
+	default:
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return fmt.Errorf("identity: parse %s: %w", path, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.mapping = mapping
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *FileStore) LookupContract(ctx context.Context, subject string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.mapping[subject]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return id, nil
+}
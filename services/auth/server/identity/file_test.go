@@ -0,0 +1,70 @@
+package identity_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"server/identity"
+)
+
+func TestLoadFileStore_YAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mapping.yaml")
+	if err := os.WriteFile(path, []byte("alice: contract-alice\nbob: contract-bob\n"), 0o644); err != nil {
+		t.Fatalf("write mapping: %v", err)
+	}
+
+	s, err := identity.LoadFileStore(path)
+	if err != nil {
+		t.Fatalf("LoadFileStore: %v", err)
+	}
+
+	id, err := s.LookupContract(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("LookupContract: %v", err)
+	}
+	if id != "contract-alice" {
+		t.Fatalf("LookupContract(alice) = %q, want contract-alice", id)
+	}
+}
+
+func TestLoadFileStore_JSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mapping.json")
+	if err := os.WriteFile(path, []byte(`{"alice": "contract-alice"}`), 0o644); err != nil {
+		t.Fatalf("write mapping: %v", err)
+	}
+
+	s, err := identity.LoadFileStore(path)
+	if err != nil {
+		t.Fatalf("LoadFileStore: %v", err)
+	}
+
+	id, err := s.LookupContract(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("LookupContract: %v", err)
+	}
+	if id != "contract-alice" {
+		t.Fatalf("LookupContract(alice) = %q, want contract-alice", id)
+	}
+}
+
+func TestFileStore_LookupContract_MissingMapping(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mapping.yaml")
+	if err := os.WriteFile(path, []byte("alice: contract-alice\n"), 0o644); err != nil {
+		t.Fatalf("write mapping: %v", err)
+	}
+
+	s, err := identity.LoadFileStore(path)
+	if err != nil {
+		t.Fatalf("LoadFileStore: %v", err)
+	}
+
+	_, err = s.LookupContract(context.Background(), "charlie")
+	if err != identity.ErrNotFound {
+		t.Fatalf("LookupContract(charlie) err = %v, want identity.ErrNotFound", err)
+	}
+}
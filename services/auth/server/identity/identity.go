@@ -0,0 +1,18 @@
+// Package identity resolves the contract ID associated with a validated
+// token's subject, so Check no longer has to hardcode a single contract ID
+// for every caller.
+package identity
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by a Store when subject has no known contract
+// mapping.
+var ErrNotFound = errors.New("identity: no contract mapping for subject")
+
+// Store resolves a token subject to a contract ID.
+type Store interface {
+	LookupContract(ctx context.Context, subject string) (string, error)
+}
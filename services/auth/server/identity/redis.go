@@ -0,0 +1,33 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore resolves contracts from plain Redis string keys, keyed as
+// KeyPrefix+subject and read with GET; hash-typed keys are not supported.
+type RedisStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisStore builds a RedisStore backed by client, prefixing every
+// lookup key with keyPrefix.
+func NewRedisStore(client *redis.Client, keyPrefix string) *RedisStore {
+	return &RedisStore{client: client, keyPrefix: keyPrefix}
+}
+
+func (s *RedisStore) LookupContract(ctx context.Context, subject string) (string, error) {
+	id, err := s.client.Get(ctx, s.keyPrefix+subject).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("identity: redis lookup %s: %w", subject, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,472 @@
+// Package oidc lets the ext_authz server act as an OIDC relying party for
+// browser traffic: unauthenticated requests to a protected path are
+// redirected to the provider's authorize endpoint, a callback endpoint
+// completes the code exchange, and the resulting tokens are kept in an
+// encrypted session cookie that is transparently refreshed as it nears
+// expiry.
+package oidc
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	goidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Config holds everything needed to act as an OIDC relying party.
+type Config struct {
+	ProviderURL  string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// CookieSecret encrypts and authenticates the state and session
+	// cookies; it is stretched to an AES-256 key via SHA-256.
+	CookieSecret string
+
+	// CallbackPath is where the server/http transport mounts the
+	// code-exchange callback, e.g. "/oidc/callback". Defaults to
+	// "/oidc/callback" if empty.
+	CallbackPath string
+
+	// ProtectedPrefixes lists the request path prefixes that require an
+	// OIDC session when no bearer token is present.
+	ProtectedPrefixes []string
+
+	// DomainWhitelist restricts which hosts a post-login redirect may
+	// point at when the original request target is an absolute URL.
+	DomainWhitelist []string
+
+	// RefreshSkew is how far ahead of expiry an access token is
+	// transparently refreshed using its refresh token.
+	RefreshSkew time.Duration
+}
+
+// Session is the authenticated state kept in the encrypted session cookie
+// between requests.
+type Session struct {
+	Subject      string
+	Role         string
+	Issuer       string
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// AuthRequest is the result of BeginLogin: where to redirect the browser
+// and the cookies that carry its encrypted state and nonce.
+type AuthRequest struct {
+	RedirectURL string
+	Cookies     []*http.Cookie
+}
+
+// CallbackResult is the outcome of completing an OIDC code exchange: where
+// to send the browser next, and the cookies that establish its session
+// (plus clear the now-spent state cookie).
+type CallbackResult struct {
+	RedirectTo string
+	Cookies    []*http.Cookie
+}
+
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	ReturnTo string `json:"return_to"`
+}
+
+const (
+	stateCookieName   = "_oidc_state"
+	sessionCookieName = "_oidc_session"
+	stateCookieTTL    = 10 * time.Minute
+
+	// cookieChunkSize keeps each Set-Cookie value comfortably under the
+	// ~4KB per-cookie limit most browsers enforce; larger encrypted blobs
+	// are split across several numbered cookies and reassembled on read.
+	cookieChunkSize = 4000
+)
+
+// Manager implements the relying-party side of an OIDC login: building the
+// authorize redirect, completing the callback, and keeping the resulting
+// session fresh.
+type Manager struct {
+	cfg Config
+
+	provider     *goidc.Provider
+	verifier     *goidc.IDTokenVerifier
+	oauth2Config oauth2.Config
+
+	gcm cipher.AEAD
+}
+
+// NewManager discovers the OIDC provider at cfg.ProviderURL and builds a
+// Manager ready to handle logins.
+func NewManager(ctx context.Context, cfg Config) (*Manager, error) {
+	if cfg.CallbackPath == "" {
+		cfg.CallbackPath = "/oidc/callback"
+	}
+	if len(cfg.CookieSecret) < 16 {
+		return nil, errors.New("oidc: cookie_secret must be set to a random value of at least 16 bytes")
+	}
+
+	provider, err := goidc.NewProvider(ctx, cfg.ProviderURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discover %s: %w", cfg.ProviderURL, err)
+	}
+
+	block, err := aes.NewCipher(deriveKey(cfg.CookieSecret))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: gcm: %w", err)
+	}
+
+	return &Manager{
+		cfg:      cfg,
+		provider: provider,
+		verifier: provider.Verifier(&goidc.Config{ClientID: cfg.ClientID}),
+		oauth2Config: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{goidc.ScopeOpenID}, cfg.Scopes...),
+		},
+		gcm: gcm,
+	}, nil
+}
+
+func deriveKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// CallbackPath returns the path the server/http transport should mount the
+// callback handler on.
+func (m *Manager) CallbackPath() string {
+	return m.cfg.CallbackPath
+}
+
+// Protects reports whether path requires an OIDC session when no bearer
+// token is present on the request.
+func (m *Manager) Protects(path string) bool {
+	for _, prefix := range m.cfg.ProtectedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) allowedRedirect(target string) bool {
+	if target == "" {
+		return true
+	}
+	// A leading "//" is protocol-relative and resolves to a different host,
+	// so it must not be treated as a same-site path like "/foo".
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") {
+		return true
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	for _, host := range m.cfg.DomainWhitelist {
+		if u.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
+// BeginLogin starts an OIDC login for a request that was about to hit
+// returnTo: it generates state and a nonce, stashes them alongside returnTo
+// in a short-lived encrypted cookie, and returns the provider's authorize
+// URL to redirect the browser to.
+func (m *Manager) BeginLogin(returnTo string) (*AuthRequest, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: generate state: %w", err)
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: generate nonce: %w", err)
+	}
+
+	blob, err := json.Marshal(loginState{State: state, Nonce: nonce, ReturnTo: returnTo})
+	if err != nil {
+		return nil, fmt.Errorf("oidc: marshal state: %w", err)
+	}
+	enc, err := m.encrypt(blob)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: encrypt state cookie: %w", err)
+	}
+
+	return &AuthRequest{
+		RedirectURL: m.oauth2Config.AuthCodeURL(state, goidc.Nonce(nonce)),
+		Cookies:     buildCookies(stateCookieName, enc, stateCookieTTL),
+	}, nil
+}
+
+// FinishLogin completes the OIDC authorization code flow for an incoming
+// callback request: it validates state against the encrypted state cookie,
+// exchanges code for tokens, verifies the ID token's nonce, and returns the
+// cookies that establish the session plus where to send the browser next.
+func (m *Manager) FinishLogin(ctx context.Context, rawCookies, code, state string) (*CallbackResult, error) {
+	cookies := parseCookies(rawCookies)
+
+	encState, ok := joinChunks(cookies, stateCookieName)
+	if !ok {
+		return nil, errors.New("oidc: missing state cookie")
+	}
+
+	blob, err := m.decrypt(encState)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: decrypt state cookie: %w", err)
+	}
+	var st loginState
+	if err := json.Unmarshal(blob, &st); err != nil {
+		return nil, fmt.Errorf("oidc: unmarshal state cookie: %w", err)
+	}
+	if state == "" || state != st.State {
+		return nil, errors.New("oidc: state mismatch")
+	}
+
+	token, err := m.oauth2Config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchange code: %w", err)
+	}
+
+	session, err := m.sessionFromToken(ctx, token, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := m.encryptSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	returnTo := st.ReturnTo
+	if !m.allowedRedirect(returnTo) {
+		returnTo = "/"
+	}
+
+	result := &CallbackResult{RedirectTo: returnTo}
+	result.Cookies = append(result.Cookies, clearCookies(stateCookieName, cookies)...)
+	result.Cookies = append(result.Cookies, buildCookies(sessionCookieName, enc, time.Until(session.Expiry))...)
+	return result, nil
+}
+
+// Authenticate reads the session cookie out of rawCookies and, if it holds
+// a still-valid session, returns it. An access token within cfg.RefreshSkew
+// of expiry is transparently refreshed using its refresh token, in which
+// case refreshedCookies carries the updated session cookie that must be set
+// on the eventual response.
+func (m *Manager) Authenticate(ctx context.Context, rawCookies string) (session *Session, refreshedCookies []*http.Cookie, err error) {
+	cookies := parseCookies(rawCookies)
+
+	enc, ok := joinChunks(cookies, sessionCookieName)
+	if !ok {
+		return nil, nil, errors.New("oidc: no session cookie")
+	}
+
+	blob, err := m.decrypt(enc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc: decrypt session cookie: %w", err)
+	}
+	var s Session
+	if err := json.Unmarshal(blob, &s); err != nil {
+		return nil, nil, fmt.Errorf("oidc: unmarshal session cookie: %w", err)
+	}
+
+	if time.Until(s.Expiry) > m.cfg.RefreshSkew {
+		return &s, nil, nil
+	}
+	if s.RefreshToken == "" {
+		return nil, nil, errors.New("oidc: session expired and has no refresh token")
+	}
+
+	token, err := m.oauth2Config.TokenSource(ctx, &oauth2.Token{RefreshToken: s.RefreshToken}).Token()
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc: refresh token: %w", err)
+	}
+
+	refreshed := s
+	refreshed.AccessToken = token.AccessToken
+	refreshed.Expiry = token.Expiry
+	if token.RefreshToken != "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+
+	enc2, err := m.encryptSession(&refreshed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &refreshed, buildCookies(sessionCookieName, enc2, time.Until(refreshed.Expiry)), nil
+}
+
+func (m *Manager) sessionFromToken(ctx context.Context, token *oauth2.Token, nonce string) (*Session, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc: token response missing id_token")
+	}
+
+	idToken, err := m.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("oidc: nonce mismatch")
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+		Role    string `json:"role"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: parse id_token claims: %w", err)
+	}
+
+	return &Session{
+		Subject:      claims.Subject,
+		Role:         claims.Role,
+		Issuer:       idToken.Issuer,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+	}, nil
+}
+
+func (m *Manager) encryptSession(s *Session) (string, error) {
+	blob, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("oidc: marshal session: %w", err)
+	}
+	enc, err := m.encrypt(blob)
+	if err != nil {
+		return "", fmt.Errorf("oidc: encrypt session cookie: %w", err)
+	}
+	return enc, nil
+}
+
+func (m *Manager) encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, m.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := m.gcm.Seal(nonce, nonce, plaintext, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (m *Manager) decrypt(value string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	size := m.gcm.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("oidc: ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:size], sealed[size:]
+	return m.gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// parseCookies turns a raw Cookie header value into a name -> value map,
+// reusing net/http's own parser rather than duplicating it.
+func parseCookies(raw string) map[string]string {
+	req := http.Request{Header: http.Header{"Cookie": []string{raw}}}
+	out := make(map[string]string)
+	for _, c := range req.Cookies() {
+		out[c.Name] = c.Value
+	}
+	return out
+}
+
+// buildCookies splits value into cookieChunkSize-sized pieces and returns
+// one cookie per piece, named base when it fits in a single cookie or
+// base_0, base_1, ... when it does not.
+func buildCookies(base, value string, maxAge time.Duration) []*http.Cookie {
+	var chunks []string
+	for len(value) > cookieChunkSize {
+		chunks = append(chunks, value[:cookieChunkSize])
+		value = value[cookieChunkSize:]
+	}
+	chunks = append(chunks, value)
+
+	cookies := make([]*http.Cookie, len(chunks))
+	for i, chunk := range chunks {
+		name := base
+		if len(chunks) > 1 {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		cookies[i] = &http.Cookie{
+			Name:     name,
+			Value:    chunk,
+			Path:     "/",
+			MaxAge:   int(maxAge.Seconds()),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		}
+	}
+	return cookies
+}
+
+// joinChunks reassembles a value previously split by buildCookies out of
+// present, a name -> value map of the cookies seen on a request.
+func joinChunks(present map[string]string, base string) (string, bool) {
+	if v, ok := present[base]; ok {
+		return v, true
+	}
+
+	var b strings.Builder
+	for i := 0; ; i++ {
+		v, ok := present[fmt.Sprintf("%s_%d", base, i)]
+		if !ok {
+			break
+		}
+		b.WriteString(v)
+	}
+	if b.Len() == 0 {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// clearCookies expires every cookie (and chunk) previously set under base,
+// so a spent state cookie does not linger in the browser.
+func clearCookies(base string, present map[string]string) []*http.Cookie {
+	var out []*http.Cookie
+	for name := range present {
+		if name != base && !strings.HasPrefix(name, base+"_") {
+			continue
+		}
+		out = append(out, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1})
+	}
+	return out
+}
@@ -0,0 +1,272 @@
+package server_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+
+	"server"
+	"server/oidc"
+)
+
+// fakeOIDCProvider is a minimal in-process OpenID Provider: enough discovery,
+// JWKS and token-endpoint surface for oidc.NewManager and a full
+// authorization-code exchange, without reaching any real network service.
+type fakeOIDCProvider struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	p := &fakeOIDCProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.srv.URL,
+			"authorization_endpoint": p.srv.URL + "/authorize",
+			"token_endpoint":         p.srv.URL + "/token",
+			"jwks_uri":               p.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64URL(p.key.PublicKey.N.Bytes()),
+				"e":   base64URL(big32(p.key.PublicKey.E)),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idToken := p.signIDToken(t, "alice", "admin")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token-1",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *fakeOIDCProvider) signIDToken(t *testing.T, subject, role string) string {
+	t.Helper()
+	claims := jwtlib.MapClaims{
+		"iss":   p.srv.URL,
+		"sub":   subject,
+		"aud":   "test-client",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"role":  role,
+		"nonce": pendingNonce,
+	}
+	tok := jwtlib.NewWithClaims(jwtlib.SigningMethodRS256, claims)
+	tok.Header["kid"] = "test-key"
+	signed, err := tok.SignedString(p.key)
+	if err != nil {
+		t.Fatalf("sign id_token: %v", err)
+	}
+	return signed
+}
+
+func big32(e int) []byte {
+	return big.NewInt(int64(e)).Bytes()
+}
+
+// pendingNonce is set by the test right before driving the callback, since
+// the fake /token handler has no other way to learn the nonce BeginLogin
+// generated.
+var pendingNonce string
+
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// TestDecide_OIDCSessionPath exercises Decide's other authentication path:
+// no bearer token, but a valid OIDC session cookie established via a full
+// BeginLogin -> FinishLogin round trip against a fake provider.
+func TestDecide_OIDCSessionPath(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+
+	mgr, err := oidc.NewManager(t.Context(), oidc.Config{
+		ProviderURL:       provider.srv.URL,
+		ClientID:          "test-client",
+		ClientSecret:      "test-secret",
+		RedirectURL:       "https://app.example.com/oidc/callback",
+		CookieSecret:      "unit-test-cookie-secret-value",
+		CallbackPath:      "/oidc/callback",
+		ProtectedPrefixes: []string{"/app"},
+		RefreshSkew:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("oidc.NewManager: %v", err)
+	}
+
+	validator := newTestValidator(t)
+	t.Cleanup(validator.Close)
+	engine := newTestEngine(t)
+	identities := &fakeIdentityStore{contracts: map[string]string{"alice": "contract-alice"}}
+	s := server.NewServer(validator, engine, identities, mgr)
+
+	// No session cookie yet: Decide must redirect into the login flow.
+	redirect, err := s.Decide(t.Context(), server.DecisionInput{Method: "GET", Path: "/app/dashboard", Headers: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Decide (pre-login): %v", err)
+	}
+	if redirect.Allow || redirect.Status != 302 {
+		t.Fatalf("status = %d, allow = %v, want 302 redirect", redirect.Status, redirect.Allow)
+	}
+	loginURL := redirect.HeadersToAdd["location"]
+	if loginURL == "" {
+		t.Fatal("expected a location header pointing at the provider's authorize endpoint")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, loginURL, nil)
+	if err != nil {
+		t.Fatalf("parse authorize URL: %v", err)
+	}
+	state := req.URL.Query().Get("state")
+	pendingNonce = req.URL.Query().Get("nonce")
+
+	var rawCookies string
+	for _, c := range redirect.SetCookies {
+		if rawCookies != "" {
+			rawCookies += "; "
+		}
+		rawCookies += fmt.Sprintf("%s=%s", c.Name, c.Value)
+	}
+
+	cbResult, err := s.FinishOIDCLogin(t.Context(), rawCookies, "test-code", state)
+	if err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+
+	var sessionCookies string
+	for _, c := range cbResult.Cookies {
+		if c.MaxAge < 0 {
+			continue // a clear-state-cookie instruction, not part of the session
+		}
+		if sessionCookies != "" {
+			sessionCookies += "; "
+		}
+		sessionCookies += fmt.Sprintf("%s=%s", c.Name, c.Value)
+	}
+
+	allowed, err := s.Decide(t.Context(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/app/dashboard",
+		Headers: map[string]string{"cookie": sessionCookies},
+	})
+	if err != nil {
+		t.Fatalf("Decide (with session): %v", err)
+	}
+	if !allowed.Allow {
+		t.Fatalf("expected allow with a valid OIDC session, got status %d body %q", allowed.Status, allowed.Body)
+	}
+	if allowed.HeadersToAdd["x-auth-subject"] != "alice" {
+		t.Fatalf("x-auth-subject = %q, want alice", allowed.HeadersToAdd["x-auth-subject"])
+	}
+}
+
+// TestDecide_OIDCSessionPath_PopulatesIssuerClaim guards against the OIDC
+// session path feeding the policy engine an empty "iss", which would make a
+// policy that branches on claims.iss behave differently depending on which
+// authentication path a request took (bearer token vs. OIDC session). The
+// policy here denies unless request.claims.iss matches the provider that
+// issued the session's ID token.
+func TestDecide_OIDCSessionPath_PopulatesIssuerClaim(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+
+	mgr, err := oidc.NewManager(t.Context(), oidc.Config{
+		ProviderURL:       provider.srv.URL,
+		ClientID:          "test-client",
+		ClientSecret:      "test-secret",
+		RedirectURL:       "https://app.example.com/oidc/callback",
+		CookieSecret:      "unit-test-cookie-secret-value",
+		CallbackPath:      "/oidc/callback",
+		ProtectedPrefixes: []string{"/app"},
+		RefreshSkew:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("oidc.NewManager: %v", err)
+	}
+
+	validator := newTestValidator(t)
+	t.Cleanup(validator.Close)
+	engine := newIssuerCheckEngine(t, provider.srv.URL)
+	identities := &fakeIdentityStore{contracts: map[string]string{"alice": "contract-alice"}}
+	s := server.NewServer(validator, engine, identities, mgr)
+
+	redirect, err := s.Decide(t.Context(), server.DecisionInput{Method: "GET", Path: "/app/dashboard", Headers: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Decide (pre-login): %v", err)
+	}
+	loginURL := redirect.HeadersToAdd["location"]
+
+	req, err := http.NewRequest(http.MethodGet, loginURL, nil)
+	if err != nil {
+		t.Fatalf("parse authorize URL: %v", err)
+	}
+	state := req.URL.Query().Get("state")
+	pendingNonce = req.URL.Query().Get("nonce")
+
+	var rawCookies string
+	for _, c := range redirect.SetCookies {
+		if rawCookies != "" {
+			rawCookies += "; "
+		}
+		rawCookies += fmt.Sprintf("%s=%s", c.Name, c.Value)
+	}
+
+	cbResult, err := s.FinishOIDCLogin(t.Context(), rawCookies, "test-code", state)
+	if err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+
+	var sessionCookies string
+	for _, c := range cbResult.Cookies {
+		if c.MaxAge < 0 {
+			continue
+		}
+		if sessionCookies != "" {
+			sessionCookies += "; "
+		}
+		sessionCookies += fmt.Sprintf("%s=%s", c.Name, c.Value)
+	}
+
+	allowed, err := s.Decide(t.Context(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/app/dashboard",
+		Headers: map[string]string{"cookie": sessionCookies},
+	})
+	if err != nil {
+		t.Fatalf("Decide (with session): %v", err)
+	}
+	if !allowed.Allow {
+		t.Fatalf("expected allow for an OIDC session whose claims.iss matches the provider, got status %d body %q", allowed.Status, allowed.Body)
+	}
+}
@@ -0,0 +1,120 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/grpc/codes"
+
+	httptransport "server/http"
+)
+
+// TestHTTPGRPCParity proves the server/http and gRPC transports reach the
+// same decision from equivalent inputs, since both are documented as thin
+// translations over a single server.Server decision core (see Server.Check
+// and httptransport.Handler.ServeHTTP).
+func TestHTTPGRPCParity(t *testing.T) {
+	s := newTestServer(t)
+	tok := signToken(t, "alice", "admin", time.Minute)
+
+	const clientAddr = "203.0.113.7"
+	const clientPort = 54321
+	const destHost = "widgets.example.com"
+
+	// gRPC side: Envoy supplies the true peer addresses directly.
+	checkReq := &auth.CheckRequest{
+		Attributes: &auth.AttributeContext{
+			Source: &auth.AttributeContext_Peer{
+				Address: &core.Address{Address: &core.Address_SocketAddress{
+					SocketAddress: &core.SocketAddress{
+						Address:       clientAddr,
+						PortSpecifier: &core.SocketAddress_PortValue{PortValue: clientPort},
+					},
+				}},
+			},
+			Destination: &auth.AttributeContext_Peer{
+				Address: &core.Address{Address: &core.Address_SocketAddress{
+					SocketAddress: &core.SocketAddress{
+						Address:       destHost,
+						PortSpecifier: &core.SocketAddress_PortValue{PortValue: 443},
+					},
+				}},
+			},
+			Request: &auth.AttributeContext_Request{
+				Http: &auth.AttributeContext_HttpRequest{
+					Method: "GET",
+					Path:   "/widgets",
+					Headers: map[string]string{
+						"authorization": "Bearer " + tok,
+					},
+				},
+			},
+		},
+	}
+
+	grpcResp, err := s.Check(t.Context(), checkReq)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if grpcResp.Status.Code != int32(codes.OK) {
+		t.Fatalf("grpc decision denied: %+v", grpcResp)
+	}
+	grpcHeaders := grpcResp.GetOkResponse().GetHeaders()
+
+	// HTTP side: Envoy's ext_authz HTTP callout carries the same downstream
+	// client and original authority in X-Forwarded-For/X-Forwarded-Host,
+	// never in RemoteAddr/Host (see sourceAddress/destinationAddress in
+	// server/http/http.go).
+	handler := httptransport.NewHandler(s)
+	httpReq := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	httpReq.Header.Set("Authorization", "Bearer "+tok)
+	httpReq.Header.Set("X-Forwarded-For", clientAddr)
+	httpReq.Header.Set("X-Forwarded-Host", destHost)
+	// RemoteAddr/Host reflect Envoy's own callout connection, not the
+	// original client - parity must not depend on these.
+	httpReq.RemoteAddr = "127.0.0.1:9901"
+	httpReq.Host = "authz-internal:5006"
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httpReq)
+
+	if rec.Code != http.StatusOK {
+		body, _ := io.ReadAll(rec.Result().Body)
+		t.Fatalf("http decision denied: status=%d body=%q", rec.Code, body)
+	}
+
+	for _, hv := range grpcHeaders {
+		key := hv.GetHeader().GetKey()
+		want := hv.GetHeader().GetValue()
+		if got := rec.Header().Get(key); got != want {
+			t.Errorf("header %q = %q, want %q (gRPC parity)", key, got, want)
+		}
+	}
+}
+
+// TestHTTPGRPCParity_AllowsWithoutForwardedFor checks that a request with no
+// X-Forwarded-For still reaches an allow decision on the HTTP transport. The
+// source address handed to the policy then falls back to RemoteAddr (covered
+// by TestSourceAddress in server/http), so a source-address policy would see
+// different values per transport unless Envoy is configured to set it.
+func TestHTTPGRPCParity_AllowsWithoutForwardedFor(t *testing.T) {
+	s := newTestServer(t)
+	tok := signToken(t, "alice", "admin", time.Minute)
+
+	handler := httptransport.NewHandler(s)
+	httpReq := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	httpReq.Header.Set("Authorization", "Bearer "+tok)
+	httpReq.RemoteAddr = "203.0.113.7:54321"
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httpReq)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected allow, got status=%d", rec.Code)
+	}
+}
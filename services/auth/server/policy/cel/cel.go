@@ -0,0 +1,131 @@
+// Package cel implements policy.Policy by evaluating a CEL expression
+// against the request. The expression may evaluate to a plain bool (a
+// simple allow/deny) or to a map with the same fields as policy.Decision
+// for finer control over status, body and headers.
+package cel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+
+	"server/policy"
+)
+
+type celPolicy struct {
+	name    string
+	program cel.Program
+}
+
+// Load compiles the CEL expression in the file at path into a policy.Policy
+// named name. The expression has a single variable, `request`, holding the
+// fields of policy.Input as a map.
+func Load(name string, path string) (policy.Policy, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cel: read %s: %w", path, err)
+	}
+
+	env, err := cel.NewEnv(cel.Variable("request", cel.DynType))
+	if err != nil {
+		return nil, fmt.Errorf("cel: new env: %w", err)
+	}
+
+	ast, issues := env.Compile(string(src))
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("cel: compile %s: %w", path, issues.Err())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("cel: program %s: %w", path, err)
+	}
+
+	return &celPolicy{name: name, program: program}, nil
+}
+
+func (p *celPolicy) Name() string { return p.name }
+
+func (p *celPolicy) Eval(ctx context.Context, in policy.Input) (*policy.Decision, error) {
+	out, _, err := p.program.ContextEval(ctx, map[string]interface{}{
+		"request": requestVars(in),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cel: eval %s: %w", p.name, err)
+	}
+
+	native, err := out.ConvertToNative(reflect.TypeOf(map[string]interface{}{}))
+	if err == nil {
+		return mapToDecision(native.(map[string]interface{}))
+	}
+
+	if allow, ok := out.Value().(bool); ok {
+		d := &policy.Decision{Allow: allow, Status: 403}
+		if allow {
+			d.Status = 200
+		}
+		return d, nil
+	}
+
+	return nil, fmt.Errorf("cel: expression must evaluate to bool or map, got %T", out.Value())
+}
+
+func requestVars(in policy.Input) map[string]interface{} {
+	return map[string]interface{}{
+		"method":              in.Method,
+		"path":                in.Path,
+		"headers":             in.Headers,
+		"source_address":      in.SourceAddress,
+		"destination_address": in.DestinationAddress,
+		"claims":              in.Claims,
+	}
+}
+
+func mapToDecision(m map[string]interface{}) (*policy.Decision, error) {
+	d := &policy.Decision{Status: 403}
+
+	if v, ok := m["allow"].(bool); ok {
+		d.Allow = v
+	}
+	if v, ok := m["status"]; ok {
+		d.Status = toInt32(v)
+	} else if d.Allow {
+		d.Status = 200
+	}
+	if v, ok := m["body"].(string); ok {
+		d.Body = v
+	}
+	if v, ok := m["add_headers"].(map[string]interface{}); ok {
+		d.HeadersToAdd = make(map[string]string, len(v))
+		for k, hv := range v {
+			if s, ok := hv.(string); ok {
+				d.HeadersToAdd[k] = s
+			}
+		}
+	}
+	if v, ok := m["remove_headers"].([]interface{}); ok {
+		for _, hv := range v {
+			if s, ok := hv.(string); ok {
+				d.HeadersToRemove = append(d.HeadersToRemove, s)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func toInt32(v interface{}) int32 {
+	switch n := v.(type) {
+	case int64:
+		return int32(n)
+	case int:
+		return int32(n)
+	case float64:
+		return int32(n)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,21 @@
+package policy
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+func readManifest(path string) ([]Route, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m.Routes, nil
+}
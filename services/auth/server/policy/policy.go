@@ -0,0 +1,219 @@
+// Package policy turns ext_authz authorization decisions into pluggable,
+// file-defined rules instead of hardcoded Go logic. Policies are loaded from
+// a directory of .cel / .rego files and matched to an incoming request by
+// path, so operators can add or change per-route authorization without a
+// rebuild.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Input is the structured view of a request that a Policy evaluates. It is
+// derived from the Envoy auth.CheckRequest plus any claims extracted from a
+// validated JWT.
+type Input struct {
+	Method  string
+	Path    string
+	Headers map[string]string
+
+	SourceAddress      string
+	DestinationAddress string
+
+	Claims map[string]interface{}
+}
+
+// Decision is the outcome of evaluating a Policy against an Input.
+type Decision struct {
+	Allow           bool
+	Status          int32
+	Body            string
+	HeadersToAdd    map[string]string
+	HeadersToRemove []string
+}
+
+// Policy evaluates an Input and returns a Decision.
+type Policy interface {
+	// Name identifies the policy, typically its source file name without
+	// extension.
+	Name() string
+	Eval(ctx context.Context, in Input) (*Decision, error)
+}
+
+// Loader compiles the contents of a policy file into a Policy. Each
+// supported file extension (".cel", ".rego") is registered with its own
+// Loader in Engine.
+type Loader func(name string, path string) (Policy, error)
+
+// Route maps requests whose path starts with Prefix to the Policy named
+// PolicyName.
+type Route struct {
+	Prefix     string `yaml:"prefix"`
+	PolicyName string `yaml:"policy"`
+}
+
+// Manifest is the optional routes.yaml at the root of a policy directory
+// describing the policy_path matcher. Routes are matched by longest prefix,
+// independent of their order in the file, so overlapping prefixes resolve to
+// the most specific one (see Engine.Match). If no manifest is present, or no
+// Route matches, the policy named "default" is used when loaded.
+type Manifest struct {
+	Routes []Route `yaml:"routes"`
+}
+
+// Engine owns the current set of loaded policies and the routing table used
+// to pick one for a given request path. It can be hot-reloaded by sending
+// the process SIGHUP.
+type Engine struct {
+	dir     string
+	loaders map[string]Loader
+
+	mu       sync.RWMutex
+	policies map[string]Policy
+	routes   []Route
+
+	sigCh chan os.Signal
+	done  chan struct{}
+}
+
+// NewEngine loads every policy file under dir using the given extension ->
+// Loader mapping, then starts a background goroutine that reloads the
+// directory whenever the process receives SIGHUP.
+func NewEngine(dir string, loaders map[string]Loader) (*Engine, error) {
+	e := &Engine{
+		dir:     dir,
+		loaders: loaders,
+		sigCh:   make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+	}
+
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+
+	signal.Notify(e.sigCh, syscall.SIGHUP)
+	go e.watch()
+
+	return e, nil
+}
+
+func (e *Engine) watch() {
+	defer close(e.done)
+	for range e.sigCh {
+		_ = e.Reload()
+	}
+}
+
+// Close stops watching for SIGHUP. It does not remove already-loaded
+// policies.
+func (e *Engine) Close() {
+	signal.Stop(e.sigCh)
+	close(e.sigCh)
+	<-e.done
+}
+
+// Reload re-reads the policy directory and its manifest, atomically
+// replacing the previous policy set on success. A failed reload leaves the
+// previously loaded policies in place.
+func (e *Engine) Reload() error {
+	policies, err := e.loadPolicies()
+	if err != nil {
+		return err
+	}
+
+	routes, err := e.loadManifest(policies)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.policies = policies
+	e.routes = routes
+	e.mu.Unlock()
+
+	return nil
+}
+
+func (e *Engine) loadPolicies() (map[string]Policy, error) {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return nil, fmt.Errorf("policy: read dir %s: %w", e.dir, err)
+	}
+
+	policies := make(map[string]Policy)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		loader, ok := e.loaders[ext]
+		if !ok {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ext)
+		path := filepath.Join(e.dir, entry.Name())
+
+		p, err := loader(name, path)
+		if err != nil {
+			return nil, fmt.Errorf("policy: load %s: %w", path, err)
+		}
+		policies[name] = p
+	}
+
+	return policies, nil
+}
+
+func (e *Engine) loadManifest(policies map[string]Policy) ([]Route, error) {
+	path := filepath.Join(e.dir, "routes.yaml")
+	routes, err := readManifest(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range routes {
+		if _, ok := policies[r.PolicyName]; !ok {
+			return nil, fmt.Errorf("policy: routes.yaml references unknown policy %q", r.PolicyName)
+		}
+	}
+
+	return routes, nil
+}
+
+// Match returns the Policy that should evaluate a request to path: the
+// longest matching route prefix from routes.yaml, or the policy named
+// "default" if no route matches or no manifest was present.
+func (e *Engine) Match(path string) (Policy, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var best *Route
+	for i := range e.routes {
+		r := &e.routes[i]
+		if !strings.HasPrefix(path, r.Prefix) {
+			continue
+		}
+		if best == nil || len(r.Prefix) > len(best.Prefix) {
+			best = r
+		}
+	}
+
+	if best != nil {
+		p, ok := e.policies[best.PolicyName]
+		return p, ok
+	}
+
+	p, ok := e.policies["default"]
+	return p, ok
+}
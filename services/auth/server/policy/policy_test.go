@@ -0,0 +1,126 @@
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"server/policy"
+	"server/policy/cel"
+)
+
+func writePolicy(t *testing.T, dir, name, src string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name+".cel"), []byte(src), 0o644); err != nil {
+		t.Fatalf("write policy %s: %v", name, err)
+	}
+}
+
+func writeManifest(t *testing.T, dir, src string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "routes.yaml"), []byte(src), 0o644); err != nil {
+		t.Fatalf("write routes.yaml: %v", err)
+	}
+}
+
+// TestEngineMatch_LongestPrefixWins pins down Engine.Match's actual
+// semantics: the most specific (longest) matching Prefix wins regardless of
+// where it appears in routes.yaml, not the first one listed.
+func TestEngineMatch_LongestPrefixWins(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "broad", `true`)
+	writePolicy(t, dir, "narrow", `true`)
+	// The more specific route is listed second, ahead of (in prefix-length
+	// terms) the broader one listed first.
+	writeManifest(t, dir, `
+routes:
+  - prefix: /widgets
+    policy: broad
+  - prefix: /widgets/admin
+    policy: narrow
+`)
+
+	e, err := policy.NewEngine(dir, map[string]policy.Loader{".cel": cel.Load})
+	if err != nil {
+		t.Fatalf("policy.NewEngine: %v", err)
+	}
+	defer e.Close()
+
+	p, ok := e.Match("/widgets/admin/users")
+	if !ok {
+		t.Fatal("expected a match")
+	}
+	if p.Name() != "narrow" {
+		t.Fatalf("Match(%q).Name() = %q, want %q (longest prefix)", "/widgets/admin/users", p.Name(), "narrow")
+	}
+
+	p, ok = e.Match("/widgets/catalog")
+	if !ok {
+		t.Fatal("expected a match")
+	}
+	if p.Name() != "broad" {
+		t.Fatalf("Match(%q).Name() = %q, want %q", "/widgets/catalog", p.Name(), "broad")
+	}
+}
+
+// TestEngineMatch_DefaultFallback documents that, with no routes.yaml
+// present, a request path falls back to the policy named "default".
+func TestEngineMatch_DefaultFallback(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "default", `true`)
+
+	e, err := policy.NewEngine(dir, map[string]policy.Loader{".cel": cel.Load})
+	if err != nil {
+		t.Fatalf("policy.NewEngine: %v", err)
+	}
+	defer e.Close()
+
+	p, ok := e.Match("/anything")
+	if !ok || p.Name() != "default" {
+		t.Fatalf("Match(%q) = (%v, %v), want (default, true)", "/anything", p, ok)
+	}
+}
+
+// TestEngineReload_SIGHUP proves a SIGHUP to the process picks up a changed
+// routes.yaml without restarting.
+func TestEngineReload_SIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "a", `true`)
+	writePolicy(t, dir, "b", `true`)
+	writeManifest(t, dir, `
+routes:
+  - prefix: /widgets
+    policy: a
+`)
+
+	e, err := policy.NewEngine(dir, map[string]policy.Loader{".cel": cel.Load})
+	if err != nil {
+		t.Fatalf("policy.NewEngine: %v", err)
+	}
+	defer e.Close()
+
+	p, ok := e.Match("/widgets")
+	if !ok || p.Name() != "a" {
+		t.Fatalf("before reload: Match(/widgets) = (%v, %v), want (a, true)", p, ok)
+	}
+
+	writeManifest(t, dir, `
+routes:
+  - prefix: /widgets
+    policy: b
+`)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("send SIGHUP: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if p, ok := e.Match("/widgets"); ok && p.Name() == "b" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("routes.yaml change was not picked up after SIGHUP")
+}
@@ -0,0 +1,109 @@
+// Package rego implements policy.Policy by evaluating an OPA Rego module
+// against the request. Each module is expected to define `package authz`
+// and a `decision` rule shaped like policy.Decision, e.g.:
+//
+//	package authz
+//
+//	default decision = {"allow": false, "status": 403, "body": "denied"}
+//
+//	decision = {"allow": true, "status": 200} {
+//	    input.method == "GET"
+//	}
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/rego"
+
+	"server/policy"
+)
+
+const query = "data.authz.decision"
+
+type regoPolicy struct {
+	name     string
+	prepared rego.PreparedEvalQuery
+}
+
+// Load compiles the Rego module at path into a policy.Policy named name.
+func Load(name string, path string) (policy.Policy, error) {
+	ctx := context.Background()
+
+	r := rego.New(
+		rego.Query(query),
+		rego.Load([]string{path}, nil),
+	)
+
+	prepared, err := r.PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rego: prepare %s: %w", path, err)
+	}
+
+	return &regoPolicy{name: name, prepared: prepared}, nil
+}
+
+func (p *regoPolicy) Name() string { return p.name }
+
+func (p *regoPolicy) Eval(ctx context.Context, in policy.Input) (*policy.Decision, error) {
+	input := map[string]interface{}{
+		"method":              in.Method,
+		"path":                in.Path,
+		"headers":             in.Headers,
+		"source_address":      in.SourceAddress,
+		"destination_address": in.DestinationAddress,
+		"claims":              in.Claims,
+	}
+
+	rs, err := p.prepared.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("rego: eval %s: %w", p.name, err)
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return nil, fmt.Errorf("rego: %s produced no decision", p.name)
+	}
+
+	m, ok := rs[0].Expressions[0].Value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rego: %s: decision must be an object, got %T", p.name, rs[0].Expressions[0].Value)
+	}
+
+	return mapToDecision(m), nil
+}
+
+func mapToDecision(m map[string]interface{}) *policy.Decision {
+	d := &policy.Decision{Status: 403}
+
+	if v, ok := m["allow"].(bool); ok {
+		d.Allow = v
+	}
+	if v, ok := m["status"].(json.Number); ok {
+		if n, err := v.Int64(); err == nil {
+			d.Status = int32(n)
+		}
+	} else if d.Allow {
+		d.Status = 200
+	}
+	if v, ok := m["body"].(string); ok {
+		d.Body = v
+	}
+	if v, ok := m["add_headers"].(map[string]interface{}); ok {
+		d.HeadersToAdd = make(map[string]string, len(v))
+		for k, hv := range v {
+			if s, ok := hv.(string); ok {
+				d.HeadersToAdd[k] = s
+			}
+		}
+	}
+	if v, ok := m["remove_headers"].([]interface{}); ok {
+		for _, hv := range v {
+			if s, ok := hv.(string); ok {
+				d.HeadersToRemove = append(d.HeadersToRemove, s)
+			}
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,106 @@
+package rego_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"server/policy"
+	"server/policy/rego"
+)
+
+func writeModule(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "default.rego")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+	return path
+}
+
+func TestLoad_DefaultAndConditionalDecision(t *testing.T) {
+	path := writeModule(t, `package authz
+
+	default decision = {"allow": false, "status": 403, "body": "denied"}
+
+	decision = {"allow": true, "status": 200} {
+		input.method == "GET"
+	}
+	`)
+
+	p, err := rego.Load("default", path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	d, err := p.Eval(context.Background(), policy.Input{Method: "GET", Path: "/widgets"})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if !d.Allow || d.Status != 200 {
+		t.Fatalf("decision = %+v, want allow=true status=200", d)
+	}
+
+	d, err = p.Eval(context.Background(), policy.Input{Method: "POST", Path: "/widgets"})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if d.Allow || d.Status != 403 || d.Body != "denied" {
+		t.Fatalf("decision = %+v, want allow=false status=403 body=denied", d)
+	}
+}
+
+func TestLoad_MapDecisionWithHeaders(t *testing.T) {
+	path := writeModule(t, `package authz
+
+	decision = {
+		"allow": true,
+		"status": 200,
+		"add_headers": {"x-auth-role": input.claims.role},
+		"remove_headers": ["authorization"],
+	} {
+		input.claims.role == "admin"
+	}
+
+	decision = {"allow": false, "status": 403, "body": "not admin"} {
+		input.claims.role != "admin"
+	}
+	`)
+
+	p, err := rego.Load("default", path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	d, err := p.Eval(context.Background(), policy.Input{
+		Method: "GET",
+		Path:   "/widgets",
+		Claims: map[string]interface{}{"role": "admin"},
+	})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if !d.Allow || d.Status != 200 {
+		t.Fatalf("decision = %+v, want allow=true status=200", d)
+	}
+	if d.HeadersToAdd["x-auth-role"] != "admin" {
+		t.Fatalf("add_headers = %+v, want x-auth-role=admin", d.HeadersToAdd)
+	}
+	if len(d.HeadersToRemove) != 1 || d.HeadersToRemove[0] != "authorization" {
+		t.Fatalf("remove_headers = %+v, want [authorization]", d.HeadersToRemove)
+	}
+
+	d, err = p.Eval(context.Background(), policy.Input{
+		Method: "GET",
+		Path:   "/widgets",
+		Claims: map[string]interface{}{"role": "guest"},
+	})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if d.Allow || d.Status != 403 || d.Body != "not admin" {
+		t.Fatalf("decision = %+v, want allow=false status=403 body=\"not admin\"", d)
+	}
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
@@ -10,38 +12,222 @@ import (
 
 	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
+
+	"server/auth/jwt"
+	"server/identity"
+	"server/oidc"
+	"server/policy"
 )
 
-type Server struct{}
+// Server implements the Envoy ext_authz Authorization service. It
+// authenticates every request, from its bearer token or, on paths protected
+// by an oidc.Manager, from its session cookie, then dispatches the actual
+// allow/deny decision to whichever policy.Policy matches the request path.
+//
+// The gRPC Check method below and the sibling server/http transport both
+// translate their own wire format to and from Decide, so a single decision
+// core backs both listeners.
+type Server struct {
+	jwt      *jwt.Validator
+	policies *policy.Engine
+	identity identity.Store
+	oidc     *oidc.Manager
+}
+
+// NewServer builds a Server that validates tokens using validator,
+// resolves contract IDs from identities, and evaluates requests against
+// policies. oidcMgr may be nil, in which case unauthenticated requests are
+// always denied rather than redirected into a login flow.
+func NewServer(validator *jwt.Validator, policies *policy.Engine, identities identity.Store, oidcMgr *oidc.Manager) *Server {
+	return &Server{jwt: validator, policies: policies, identity: identities, oidc: oidcMgr}
+}
+
+// DecisionInput is the transport-agnostic view of a request to authorize.
+type DecisionInput struct {
+	Method  string
+	Path    string
+	Headers map[string]string
+
+	SourceAddress      string
+	DestinationAddress string
+}
+
+// DecisionResult is the transport-agnostic outcome of authorizing a
+// DecisionInput.
+type DecisionResult struct {
+	Allow           bool
+	Status          int32
+	Body            string
+	HeadersToAdd    map[string]string
+	HeadersToRemove []string
+	SetCookies      []*http.Cookie
+}
+
+// Decide authenticates in, either from its bearer token or, for paths an
+// oidc.Manager protects, from the browser's session cookie, then evaluates
+// the policy.Policy matching in.Path. It is the single decision core shared
+// by the gRPC Check method and the server/http transport.
+func (a *Server) Decide(ctx context.Context, in DecisionInput) (*DecisionResult, error) {
+	claims, extraHeaders, extraCookies, deny := a.authenticate(ctx, in)
+	if deny != nil {
+		return deny, nil
+	}
+
+	contractID, err := a.identity.LookupContract(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, identity.ErrNotFound) {
+			return deniedResult(403, "no contract mapping for subject"), nil
+		}
+		return deniedResult(500, fmt.Sprintf("identity lookup: %v", err)), nil
+	}
+
+	p, ok := a.policies.Match(in.Path)
+	if !ok {
+		return deniedResult(403, "no policy configured for this route"), nil
+	}
+
+	decision, err := p.Eval(ctx, policy.Input{
+		Method:             in.Method,
+		Path:               in.Path,
+		Headers:            in.Headers,
+		SourceAddress:      in.SourceAddress,
+		DestinationAddress: in.DestinationAddress,
+		Claims:             claimsToMap(claims, contractID),
+	})
+	if err != nil {
+		return deniedResult(500, fmt.Sprintf("policy %q: %v", p.Name(), err)), nil
+	}
+
+	if !decision.Allow {
+		return deniedResult(decision.Status, decision.Body), nil
+	}
+
+	result := allowedResult(claims, contractID, decision)
+	for k, v := range extraHeaders {
+		result.HeadersToAdd[k] = v
+	}
+	result.SetCookies = extraCookies
+	return result, nil
+}
+
+// authenticate resolves the claims for in, either from its bearer token or,
+// for a path an oidc.Manager protects, from the browser's session cookie.
+// deny is non-nil when the request must be rejected or redirected
+// immediately, without reaching the policy engine. extraHeaders and
+// extraCookies carry response data that must ride along with the eventual
+// allow decision, such as a rewritten upstream Authorization header or a
+// refreshed session cookie.
+func (a *Server) authenticate(ctx context.Context, in DecisionInput) (claims *jwt.Claims, extraHeaders map[string]string, extraCookies []*http.Cookie, deny *DecisionResult) {
+	raw, ok := in.Headers[a.jwt.HeaderName()]
+	if !ok {
+		if a.oidc != nil && a.oidc.Protects(in.Path) {
+			return a.authenticateOIDC(ctx, in)
+		}
+		return nil, nil, nil, deniedResult(401, string(jwt.ReasonMissingToken))
+	}
+
+	token, ok := jwt.ExtractToken(raw)
+	if !ok {
+		return nil, nil, nil, deniedResult(401, string(jwt.ReasonMissingToken))
+	}
+
+	c, err := a.jwt.Validate(token)
+	if err != nil {
+		reason := jwt.ReasonMalformed
+		var verr *jwt.ValidationError
+		if errors.As(err, &verr) {
+			reason = verr.Reason
+		}
+		return nil, nil, nil, deniedResult(401, string(reason))
+	}
+
+	return c, nil, nil, nil
+}
+
+// authenticateOIDC authenticates a request with no bearer token against its
+// OIDC session cookie, or, if it has none (or an expired one with no usable
+// refresh token), denies it with a redirect into the login flow.
+func (a *Server) authenticateOIDC(ctx context.Context, in DecisionInput) (claims *jwt.Claims, extraHeaders map[string]string, extraCookies []*http.Cookie, deny *DecisionResult) {
+	session, refreshedCookies, err := a.oidc.Authenticate(ctx, in.Headers["cookie"])
+	if err == nil {
+		c := &jwt.Claims{Role: session.Role}
+		c.Subject = session.Subject
+		c.Issuer = session.Issuer
+		return c, map[string]string{"authorization": "Bearer " + session.AccessToken}, refreshedCookies, nil
+	}
+
+	req, err := a.oidc.BeginLogin(in.Path)
+	if err != nil {
+		return nil, nil, nil, deniedResult(500, fmt.Sprintf("oidc: %v", err))
+	}
+
+	result := deniedResult(302, "")
+	result.HeadersToAdd = map[string]string{"location": req.RedirectURL}
+	result.SetCookies = req.Cookies
+	return nil, nil, nil, result
+}
+
+func deniedResult(status int32, body string) *DecisionResult {
+	return &DecisionResult{Allow: false, Status: status, Body: body}
+}
+
+func allowedResult(claims *jwt.Claims, contractID string, d *policy.Decision) *DecisionResult {
+	headers := map[string]string{"x-auth-contract-id": contractID}
+	if claims.Subject != "" {
+		headers["x-auth-subject"] = claims.Subject
+	}
+	if claims.Role != "" {
+		headers["x-auth-role"] = claims.Role
+	}
+	for k, v := range d.HeadersToAdd {
+		headers[k] = v
+	}
+
+	return &DecisionResult{
+		Allow:           true,
+		Status:          200,
+		HeadersToAdd:    headers,
+		HeadersToRemove: append([]string{"token"}, d.HeadersToRemove...),
+	}
+}
+
+func denied(result *DecisionResult) *auth.CheckResponse {
+	resp := &auth.DeniedHttpResponse{
+		Status: &envoy_type.HttpStatus{Code: envoy_type.StatusCode(result.Status)},
+		Body:   result.Body,
+	}
+	resp.Headers = append(resp.Headers, headerValueOptions(result.HeadersToAdd, result.SetCookies)...)
 
-func denied(code int32, body string) *auth.CheckResponse {
 	return &auth.CheckResponse{
-		Status: &status.Status{Code: code},
-		HttpResponse: &auth.CheckResponse_DeniedResponse{
-			DeniedResponse: &auth.DeniedHttpResponse{
-				Status: &envoy_type.HttpStatus{
-					Code: envoy_type.StatusCode(code),
-				},
-				Body: body,
-			},
-		},
+		Status:       &status.Status{Code: result.Status},
+		HttpResponse: &auth.CheckResponse_DeniedResponse{DeniedResponse: resp},
 	}
 }
 
-func allowed() *auth.CheckResponse {
+func headerValueOptions(headers map[string]string, cookies []*http.Cookie) []*core.HeaderValueOption {
+	opts := make([]*core.HeaderValueOption, 0, len(headers)+len(cookies))
+	for k, v := range headers {
+		opts = append(opts, &core.HeaderValueOption{
+			Header: &core.HeaderValue{Key: k, Value: v},
+		})
+	}
+	for _, c := range cookies {
+		opts = append(opts, &core.HeaderValueOption{
+			Header: &core.HeaderValue{Key: "set-cookie", Value: c.String()},
+		})
+	}
+	return opts
+}
+
+func allowed(result *DecisionResult) *auth.CheckResponse {
+	headers := headerValueOptions(result.HeadersToAdd, result.SetCookies)
+
 	return &auth.CheckResponse{
 		Status: &status.Status{Code: int32(codes.OK)},
 		HttpResponse: &auth.CheckResponse_OkResponse{
 			OkResponse: &auth.OkHttpResponse{
-				Headers: []*core.HeaderValueOption{
-					{
-						Header: &core.HeaderValue{
-							Key:   "x-auth-contract-id",
-							Value: "FX6glXnwnPljB/ayPW/WHDz/EjB21Ewn4um+3wITXoc=",
-						},
-					},
-				},
-				HeadersToRemove: []string{"token"},
+				Headers:         headers,
+				HeadersToRemove: result.HeadersToRemove,
 			},
 		},
 	}
@@ -49,19 +235,63 @@ func allowed() *auth.CheckResponse {
 
 // Check implements Envoy Authorization service. Proto file:
 // https://github.com/envoyproxy/envoy/blob/main/api/envoy/service/auth/v3/external_auth.proto
+//
+// It translates the gRPC CheckRequest into a DecisionInput, delegates to
+// Decide, and translates the result back into a CheckResponse.
 func (a *Server) Check(ctx context.Context, req *auth.CheckRequest) (*auth.CheckResponse, error) {
-	headers := req.Attributes.Request.Http.Headers
+	httpReq := req.Attributes.Request.Http
+	headers := httpReq.Headers
+
+	result, err := a.Decide(ctx, DecisionInput{
+		Method:             httpReq.Method,
+		Path:               httpReq.Path,
+		Headers:            headers,
+		SourceAddress:      peerAddress(req.Attributes.Source),
+		DestinationAddress: peerAddress(req.Attributes.Destination),
+	})
+	if err != nil {
+		return denied(deniedResult(500, err.Error())), nil
+	}
+
+	if !result.Allow {
+		return denied(result), nil
+	}
+
+	return allowed(result), nil
+}
+
+// FinishOIDCLogin completes an OIDC authorization code callback. It is
+// called by the server/http transport's dedicated callback endpoint, never
+// by Check or Decide.
+func (a *Server) FinishOIDCLogin(ctx context.Context, rawCookies, code, state string) (*oidc.CallbackResult, error) {
+	if a.oidc == nil {
+		return nil, errors.New("server: oidc is not configured")
+	}
+	return a.oidc.FinishLogin(ctx, rawCookies, code, state)
+}
 
-	fmt.Println("=== Request headers ===")
-	for h, v := range headers {
-		fmt.Printf("%s: %s\n", h, v)
+// OIDCCallbackPath returns the path the server/http transport should mount
+// the OIDC callback handler on, or "" if OIDC is not configured.
+func (a *Server) OIDCCallbackPath() string {
+	if a.oidc == nil {
+		return ""
 	}
-	fmt.Println("=======================")
+	return a.oidc.CallbackPath()
+}
 
-	// TODO: Do some check here.
-	// if headers["token"] != "abc" {
-	// 	return denied(401, "unauthenticated"), nil
-	// }
+func peerAddress(peer *auth.AttributeContext_Peer) string {
+	socket := peer.GetAddress().GetSocketAddress()
+	if socket == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", socket.GetAddress(), socket.GetPortValue())
+}
 
-	return allowed(), nil
+func claimsToMap(claims *jwt.Claims, contractID string) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":         claims.Subject,
+		"role":        claims.Role,
+		"contract_id": contractID,
+		"iss":         claims.Issuer,
+	}
 }
@@ -0,0 +1,254 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+
+	"server"
+	"server/auth/jwt"
+	"server/config"
+	"server/identity"
+	"server/policy"
+	"server/policy/cel"
+)
+
+const (
+	testIssuer   = "https://issuer.test"
+	testAudience = "ext_authz"
+	testSecret   = "unit-test-hs256-secret"
+)
+
+// fakeIdentityStore resolves a fixed set of subjects to contract IDs, as a
+// stand-in for identity.LoadFileStore/NewRedisStore/DialGRPCStore in tests.
+type fakeIdentityStore struct {
+	contracts map[string]string
+}
+
+func (s *fakeIdentityStore) LookupContract(ctx context.Context, subject string) (string, error) {
+	id, ok := s.contracts[subject]
+	if !ok {
+		return "", identity.ErrNotFound
+	}
+	return id, nil
+}
+
+func newTestValidator(t *testing.T) *jwt.Validator {
+	t.Helper()
+	v, err := jwt.NewValidator(context.Background(), config.JWT{
+		HeaderName:  "authorization",
+		Issuer:      testIssuer,
+		Audience:    testAudience,
+		HS256Secret: testSecret,
+	})
+	if err != nil {
+		t.Fatalf("jwt.NewValidator: %v", err)
+	}
+	return v
+}
+
+// signToken builds an HS256 token signed with testSecret for subject with
+// the given role, expiring in ttl.
+func signToken(t *testing.T, subject, role string, ttl time.Duration) string {
+	t.Helper()
+	claims := struct {
+		jwtlib.RegisteredClaims
+		Role string `json:"role,omitempty"`
+	}{
+		RegisteredClaims: jwtlib.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    testIssuer,
+			Audience:  jwtlib.ClaimStrings{testAudience},
+			ExpiresAt: jwtlib.NewNumericDate(time.Now().Add(ttl)),
+		},
+		Role: role,
+	}
+	tok, err := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return tok
+}
+
+// newTestEngine loads a single CEL policy, named "default", that allows
+// requests whose claims.role is "admin".
+func newTestEngine(t *testing.T) *policy.Engine {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "default.cel"), []byte(`request.claims.role == "admin"`), 0o644); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	e, err := policy.NewEngine(dir, map[string]policy.Loader{".cel": cel.Load})
+	if err != nil {
+		t.Fatalf("policy.NewEngine: %v", err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+// newIssuerCheckEngine loads a single CEL policy, named "default", that
+// allows requests only when claims.iss matches wantIssuer - used to prove
+// that every authentication path (bearer token and OIDC session alike)
+// feeds the policy engine the same "iss" claim.
+func newIssuerCheckEngine(t *testing.T, wantIssuer string) *policy.Engine {
+	t.Helper()
+	dir := t.TempDir()
+	policySrc := fmt.Sprintf(`request.claims.iss == %q`, wantIssuer)
+	if err := os.WriteFile(filepath.Join(dir, "default.cel"), []byte(policySrc), 0o644); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	e, err := policy.NewEngine(dir, map[string]policy.Loader{".cel": cel.Load})
+	if err != nil {
+		t.Fatalf("policy.NewEngine: %v", err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+func newTestServer(t *testing.T) *server.Server {
+	t.Helper()
+	validator := newTestValidator(t)
+	t.Cleanup(validator.Close)
+
+	engine := newTestEngine(t)
+	identities := &fakeIdentityStore{contracts: map[string]string{
+		"alice": "contract-alice",
+		"bob":   "contract-bob",
+	}}
+
+	return server.NewServer(validator, engine, identities, nil)
+}
+
+func TestDecide_MissingToken(t *testing.T) {
+	s := newTestServer(t)
+
+	result, err := s.Decide(context.Background(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/widgets",
+		Headers: map[string]string{},
+	})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if result.Allow {
+		t.Fatal("expected deny for missing token")
+	}
+	if result.Status != 401 {
+		t.Fatalf("status = %d, want 401", result.Status)
+	}
+}
+
+func TestDecide_InvalidSignature(t *testing.T) {
+	s := newTestServer(t)
+
+	tok, err := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, jwtlib.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    testIssuer,
+		Audience:  jwtlib.ClaimStrings{testAudience},
+		ExpiresAt: jwtlib.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString([]byte("wrong-secret"))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	result, err := s.Decide(context.Background(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/widgets",
+		Headers: map[string]string{"authorization": "Bearer " + tok},
+	})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if result.Allow {
+		t.Fatal("expected deny for invalid signature")
+	}
+	if result.Status != 401 {
+		t.Fatalf("status = %d, want 401", result.Status)
+	}
+}
+
+func TestDecide_UnknownIdentity(t *testing.T) {
+	s := newTestServer(t)
+	tok := signToken(t, "charlie", "admin", time.Minute)
+
+	result, err := s.Decide(context.Background(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/widgets",
+		Headers: map[string]string{"authorization": "Bearer " + tok},
+	})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if result.Allow {
+		t.Fatal("expected deny for subject with no identity mapping")
+	}
+	if result.Status != 403 {
+		t.Fatalf("status = %d, want 403", result.Status)
+	}
+}
+
+func TestDecide_PolicyDenies(t *testing.T) {
+	s := newTestServer(t)
+	tok := signToken(t, "bob", "user", time.Minute)
+
+	result, err := s.Decide(context.Background(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/widgets",
+		Headers: map[string]string{"authorization": "Bearer " + tok},
+	})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if result.Allow {
+		t.Fatal("expected deny: role is not admin")
+	}
+	if result.Status != 403 {
+		t.Fatalf("status = %d, want 403", result.Status)
+	}
+}
+
+func TestDecide_Allows(t *testing.T) {
+	s := newTestServer(t)
+	tok := signToken(t, "alice", "admin", time.Minute)
+
+	result, err := s.Decide(context.Background(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/widgets",
+		Headers: map[string]string{"authorization": "Bearer " + tok},
+	})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if !result.Allow {
+		t.Fatalf("expected allow, got status %d body %q", result.Status, result.Body)
+	}
+	if result.HeadersToAdd["x-auth-contract-id"] != "contract-alice" {
+		t.Fatalf("x-auth-contract-id = %q, want contract-alice", result.HeadersToAdd["x-auth-contract-id"])
+	}
+	if result.HeadersToAdd["x-auth-subject"] != "alice" {
+		t.Fatalf("x-auth-subject = %q, want alice", result.HeadersToAdd["x-auth-subject"])
+	}
+}
+
+func TestDecide_NoOIDCConfiguredDeniesMissingToken(t *testing.T) {
+	// With no oidc.Manager wired in, a missing token is denied outright even
+	// for a path that would otherwise be protected by a login redirect.
+	s := newTestServer(t)
+
+	result, err := s.Decide(context.Background(), server.DecisionInput{
+		Method:  "GET",
+		Path:    "/app/dashboard",
+		Headers: map[string]string{},
+	})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if result.Allow || result.Status != 401 {
+		t.Fatalf("status = %d, allow = %v, want 401 deny", result.Status, result.Allow)
+	}
+}